@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// --- Workload Configuration ---
+//
+// A workload file (YAML or JSON, selected by extension) describes everything
+// that used to be hardcoded at the top of main(): where to connect, how many
+// workers to run, how long to run for, and the mix of queries to dispatch.
+
+// WorkloadConfig is the root of the workload config file.
+type WorkloadConfig struct {
+	Connection ConnectionConfig `yaml:"connection" json:"connection"`
+	Workers    int              `yaml:"workers" json:"workers"`
+	Duration   string           `yaml:"duration" json:"duration"`
+	Metrics    MetricsConfig    `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler,omitempty" json:"scheduler,omitempty"`
+	Report     ReportConfig     `yaml:"report,omitempty" json:"report,omitempty"`
+	Queries    []WorkloadQuery  `yaml:"queries" json:"queries"`
+}
+
+// ConnectionConfig holds everything needed to dial the cluster and bucket.
+type ConnectionConfig struct {
+	ConnectionString string `yaml:"connectionString" json:"connectionString"`
+	Username         string `yaml:"username" json:"username"`
+	Password         string `yaml:"password" json:"password"`
+	BucketName       string `yaml:"bucket" json:"bucket"`
+	// ScopeName is substituted into a WorkloadQuery's N1QL wherever it
+	// contains the literal "{{scope}}" placeholder (BucketName is likewise
+	// substituted for "{{bucket}}"), so query text doesn't have to repeat
+	// the bucket/scope inline.
+	ScopeName string `yaml:"scope" json:"scope"`
+	// CACertPath optionally overrides the embedded Capella CA certificate
+	// with one read from disk.
+	CACertPath string `yaml:"caCertPath" json:"caCertPath"`
+	// Vault, if set, sources dynamic short-lived credentials instead of
+	// using Username/Password directly.
+	Vault *VaultConfig `yaml:"vault,omitempty" json:"vault,omitempty"`
+}
+
+// WorkloadQuery is a single named N1QL statement, its relative weight in the
+// dispatch mix, and the generators used to fill its named parameters.
+type WorkloadQuery struct {
+	Name   string                     `yaml:"name" json:"name"`
+	N1QL   string                     `yaml:"n1ql" json:"n1ql"`
+	Weight int                        `yaml:"weight" json:"weight"`
+	Params map[string]*ParamGenerator `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// ParamGenerator describes how to produce the value of one named parameter
+// each time its query is dispatched.
+//
+// Supported Types: "static" (always Value), "round_robin" (cycle through
+// Values in order), "random_list" (pick uniformly from Values), "random_int"
+// (uniform integer in [Min, Max]), and "uuid" (a fresh random UUID string).
+type ParamGenerator struct {
+	Type   string        `yaml:"type" json:"type"`
+	Value  interface{}   `yaml:"value,omitempty" json:"value,omitempty"`
+	Values []interface{} `yaml:"values,omitempty" json:"values,omitempty"`
+	Min    int           `yaml:"min,omitempty" json:"min,omitempty"`
+	Max    int           `yaml:"max,omitempty" json:"max,omitempty"`
+
+	// next is the round-robin cursor. It is only ever advanced from the
+	// single dispatch goroutine, so it needs no synchronization.
+	next uint64
+}
+
+// Redacted returns a copy of the config with secrets blanked out, suitable
+// for embedding in a persisted report.
+func (c *WorkloadConfig) Redacted() *WorkloadConfig {
+	redacted := *c
+	redacted.Connection.Password = ""
+	if c.Connection.Vault != nil {
+		vault := *c.Connection.Vault
+		vault.Token = ""
+		vault.SecretID = ""
+		redacted.Connection.Vault = &vault
+	}
+	return &redacted
+}
+
+// LoadWorkloadConfig reads and parses a workload config file, dispatching on
+// its extension (.yaml/.yml or .json).
+func LoadWorkloadConfig(path string) (*WorkloadConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %q: %w", path, err)
+	}
+
+	cfg := &WorkloadConfig{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing YAML config %q: %w", path, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing JSON config %q: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config extension %q (want .yaml, .yml or .json)", ext)
+	}
+
+	if len(cfg.Queries) == 0 {
+		return nil, fmt.Errorf("config %q defines no queries", path)
+	}
+	for i := range cfg.Queries {
+		if cfg.Queries[i].Weight <= 0 {
+			cfg.Queries[i].Weight = 1
+		}
+		cfg.Queries[i].N1QL = strings.NewReplacer(
+			"{{bucket}}", cfg.Connection.BucketName,
+			"{{scope}}", cfg.Connection.ScopeName,
+		).Replace(cfg.Queries[i].N1QL)
+	}
+	return cfg, nil
+}
+
+// Generate produces the next value for this parameter according to its Type.
+func (g *ParamGenerator) Generate() (interface{}, error) {
+	switch g.Type {
+	case "", "static":
+		return g.Value, nil
+	case "round_robin":
+		if len(g.Values) == 0 {
+			return nil, fmt.Errorf("round_robin generator has no values")
+		}
+		v := g.Values[g.next%uint64(len(g.Values))]
+		g.next++
+		return v, nil
+	case "random_list":
+		if len(g.Values) == 0 {
+			return nil, fmt.Errorf("random_list generator has no values")
+		}
+		return g.Values[rand.Intn(len(g.Values))], nil
+	case "random_int":
+		if g.Max < g.Min {
+			return nil, fmt.Errorf("random_int generator has max %d < min %d", g.Max, g.Min)
+		}
+		return g.Min + rand.Intn(g.Max-g.Min+1), nil
+	case "uuid":
+		return uuid.NewString(), nil
+	default:
+		return nil, fmt.Errorf("unknown param generator type %q", g.Type)
+	}
+}
+
+// WeightedQueryPicker selects queries from a fixed list according to their
+// relative weight, replacing a fixed round-robin sequence.
+type WeightedQueryPicker struct {
+	queries     []WorkloadQuery
+	cumWeights  []int
+	totalWeight int
+}
+
+// NewWeightedQueryPicker builds a picker over the given queries. Queries must
+// already have a positive Weight (LoadWorkloadConfig normalizes this).
+func NewWeightedQueryPicker(queries []WorkloadQuery) *WeightedQueryPicker {
+	p := &WeightedQueryPicker{
+		queries:    queries,
+		cumWeights: make([]int, len(queries)),
+	}
+	running := 0
+	for i, q := range queries {
+		running += q.Weight
+		p.cumWeights[i] = running
+	}
+	p.totalWeight = running
+	return p
+}
+
+// Pick returns the next query to dispatch, chosen at random in proportion to
+// its configured weight.
+func (p *WeightedQueryPicker) Pick() *WorkloadQuery {
+	r := rand.Intn(p.totalWeight) + 1
+	for i, cw := range p.cumWeights {
+		if r <= cw {
+			return &p.queries[i]
+		}
+	}
+	return &p.queries[len(p.queries)-1]
+}
+
+// BuildParams generates the named-parameter map for a single dispatch of q.
+func BuildParams(q *WorkloadQuery) (map[string]interface{}, error) {
+	if len(q.Params) == 0 {
+		return nil, nil
+	}
+	params := make(map[string]interface{}, len(q.Params))
+	for name, gen := range q.Params {
+		v, err := gen.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("generating param %q for query %q: %w", name, q.Name, err)
+		}
+		params[name] = v
+	}
+	return params, nil
+}
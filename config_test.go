@@ -0,0 +1,125 @@
+package main
+
+import "testing"
+
+func TestParamGenerator_Generate(t *testing.T) {
+	t.Run("static", func(t *testing.T) {
+		g := &ParamGenerator{Type: "static", Value: "foo"}
+		v, err := g.Generate()
+		if err != nil || v != "foo" {
+			t.Fatalf("got %v, %v; want \"foo\", nil", v, err)
+		}
+	})
+
+	t.Run("round_robin cycles in order", func(t *testing.T) {
+		g := &ParamGenerator{Type: "round_robin", Values: []interface{}{"a", "b", "c"}}
+		for i, want := range []interface{}{"a", "b", "c", "a", "b"} {
+			got, err := g.Generate()
+			if err != nil {
+				t.Fatalf("iteration %d: unexpected error: %v", i, err)
+			}
+			if got != want {
+				t.Fatalf("iteration %d: got %v, want %v", i, got, want)
+			}
+		}
+	})
+
+	t.Run("round_robin empty values errors", func(t *testing.T) {
+		g := &ParamGenerator{Type: "round_robin"}
+		if _, err := g.Generate(); err == nil {
+			t.Fatal("expected error for empty round_robin values")
+		}
+	})
+
+	t.Run("random_list picks from values", func(t *testing.T) {
+		g := &ParamGenerator{Type: "random_list", Values: []interface{}{"x", "y", "z"}}
+		for i := 0; i < 20; i++ {
+			v, err := g.Generate()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch v {
+			case "x", "y", "z":
+			default:
+				t.Fatalf("got unexpected value %v", v)
+			}
+		}
+	})
+
+	t.Run("random_int within range", func(t *testing.T) {
+		g := &ParamGenerator{Type: "random_int", Min: 5, Max: 7}
+		for i := 0; i < 50; i++ {
+			v, err := g.Generate()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			n, ok := v.(int)
+			if !ok || n < 5 || n > 7 {
+				t.Fatalf("got %v, want int in [5,7]", v)
+			}
+		}
+	})
+
+	t.Run("random_int max less than min errors", func(t *testing.T) {
+		g := &ParamGenerator{Type: "random_int", Min: 10, Max: 1}
+		if _, err := g.Generate(); err == nil {
+			t.Fatal("expected error when max < min")
+		}
+	})
+
+	t.Run("uuid generates distinct values", func(t *testing.T) {
+		g := &ParamGenerator{Type: "uuid"}
+		first, err := g.Generate()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := g.Generate()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first == second {
+			t.Fatalf("expected distinct uuids, got %v twice", first)
+		}
+	})
+
+	t.Run("unknown type errors", func(t *testing.T) {
+		g := &ParamGenerator{Type: "bogus"}
+		if _, err := g.Generate(); err == nil {
+			t.Fatal("expected error for unknown generator type")
+		}
+	})
+}
+
+func TestWeightedQueryPicker_Pick(t *testing.T) {
+	queries := []WorkloadQuery{
+		{Name: "common", Weight: 99},
+		{Name: "rare", Weight: 1},
+	}
+	picker := NewWeightedQueryPicker(queries)
+
+	counts := map[string]int{}
+	const iterations = 5000
+	for i := 0; i < iterations; i++ {
+		counts[picker.Pick().Name]++
+	}
+
+	if counts["common"] == 0 {
+		t.Fatal("expected the heavily-weighted query to be picked at least once")
+	}
+	if counts["rare"] == 0 {
+		t.Fatal("expected the lightly-weighted query to be picked at least once")
+	}
+	if counts["common"] <= counts["rare"] {
+		t.Fatalf("expected common (%d) to be picked far more often than rare (%d)", counts["common"], counts["rare"])
+	}
+}
+
+func TestWeightedQueryPicker_SingleQuery(t *testing.T) {
+	queries := []WorkloadQuery{{Name: "only", Weight: 1}}
+	picker := NewWeightedQueryPicker(queries)
+	for i := 0; i < 10; i++ {
+		if got := picker.Pick().Name; got != "only" {
+			t.Fatalf("got %q, want %q", got, "only")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// --- Prometheus Metrics ---
+//
+// Exposes /metrics in Prometheus text format so a long run can be scraped
+// and graphed live instead of waiting on the final stdout summary.
+
+var (
+	queryOutcomesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "query_loader_query_outcomes_total",
+		Help: "Count of query outcomes, labeled by query name, outcome, and error code.",
+	}, []string{"query", "outcome", "error_code"})
+
+	queryLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "query_loader_query_latency_seconds",
+		Help:    "Latency of cluster.Query plus its row-drain, labeled by query name.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query"})
+
+	inFlightQueries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "query_loader_in_flight_queries",
+		Help: "Number of queries currently executing across all workers.",
+	})
+
+	activeWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "query_loader_active_workers",
+		Help: "Number of worker goroutines currently running.",
+	})
+)
+
+// MetricsConfig configures the /metrics HTTP endpoint.
+type MetricsConfig struct {
+	// Port defaults to 9090 when unset.
+	Port int `yaml:"port,omitempty" json:"port,omitempty"`
+}
+
+// StartMetricsServer launches the /metrics endpoint in the background. It
+// does not block; a failure to bind is logged but does not abort the run.
+func StartMetricsServer(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	addr := fmt.Sprintf(":%d", port)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Printf("WARN: Metrics server on %s stopped: %v\n", addr, err)
+		}
+	}()
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/x509" // Added for handling certificates
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -15,8 +17,9 @@ import (
 
 // --- Configuration ---
 const (
-	SCRIPT_VERSION                 = "V12_SUCCESS_RATE_CALCS"
-	numWorkers                     = 10
+	SCRIPT_VERSION                 = "V14_STRUCTURED_REPORTING"
+	defaultWorkers                 = 10
+	defaultDuration                = 15 * time.Minute
 	conflictErrorCode       uint32 = 12009
 	requestAbortedErrorCode uint32 = 1195
 )
@@ -64,16 +67,21 @@ type QueryInfo struct {
 
 // QueryResult uses an Outcome string for better categorization.
 type QueryResult struct {
-	Name     string
-	Outcome  string
-	ErrorMsg string
+	Name      string
+	Outcome   string
+	ErrorMsg  string
+	ErrorCode string // parsed N1QL error code, "" for successes
+	Latency   time.Duration
 }
 
-// StatCounter holds the categorized results.
+// StatCounter holds the categorized results. Failures is keyed by parsed
+// N1QL error code so runs can be diffed programmatically; FailureMessages is
+// keyed by the raw error message for the human-readable console breakdown.
 type StatCounter struct {
-	Success   int
-	Conflicts int
-	Failures  map[string]int
+	Success         int
+	Conflicts       int
+	Failures        map[string]int
+	FailureMessages map[string]int
 }
 
 // FileLogger is a custom logger that satisfies the gocb.Logger interface
@@ -91,7 +99,8 @@ func (l *FileLogger) Log(level gocb.LogLevel, offset int, format string, v ...in
 // NewStatCounter initializes a new StatCounter.
 func NewStatCounter() *StatCounter {
 	return &StatCounter{
-		Failures: make(map[string]int),
+		Failures:        make(map[string]int),
+		FailureMessages: make(map[string]int),
 	}
 }
 
@@ -113,7 +122,7 @@ func handleQueryError(id int, task QueryInfo, err error, appLogger *log.Logger)
 
 		if isOnlyConflicts {
 			appLogger.Printf("APP: Worker %d recorded a CONFLICT on query '%s'", id, task.Name)
-			return QueryResult{Name: task.Name, Outcome: OutcomeConflict}
+			return QueryResult{Name: task.Name, Outcome: OutcomeConflict, ErrorCode: fmt.Sprintf("%d", conflictErrorCode)}
 		} else {
 			errorJSON, jsonErr := json.Marshal(queryErr)
 			var errorMsg string
@@ -122,16 +131,30 @@ func handleQueryError(id int, task QueryInfo, err error, appLogger *log.Logger)
 			} else {
 				errorMsg = string(errorJSON)
 			}
+			errorCode := "unknown"
+			if len(queryErr.Errors) > 0 {
+				errorCode = fmt.Sprintf("%d", queryErr.Errors[0].Code)
+			}
 			appLogger.Printf("APP: Worker %d recorded a FAILURE on query '%s': %s", id, task.Name, errorMsg)
-			return QueryResult{Name: task.Name, Outcome: OutcomeFailure, ErrorMsg: errorMsg}
+			return QueryResult{Name: task.Name, Outcome: OutcomeFailure, ErrorMsg: errorMsg, ErrorCode: errorCode}
 		}
 	} else {
 		appLogger.Printf("APP: Worker %d recorded a FAILURE on query '%s': %s", id, task.Name, err.Error())
-		return QueryResult{Name: task.Name, Outcome: OutcomeFailure, ErrorMsg: err.Error()}
+		return QueryResult{Name: task.Name, Outcome: OutcomeFailure, ErrorMsg: err.Error(), ErrorCode: "unknown"}
 	}
 }
 
 func main() {
+	// --- Flags ---
+	configPath := flag.String("config", "workload.yaml", "path to the workload config file (YAML or JSON)")
+	flag.Parse()
+
+	workload, err := LoadWorkloadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("FATAL: Failed to load workload config: %v\n", err)
+		os.Exit(1)
+	}
+
 	// --- App Log File Setup ---
 	appLogFile, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
@@ -152,20 +175,53 @@ func main() {
 	sdkCustomLogger := &FileLogger{logger: sdkStdLogger}
 	gocb.SetLogger(sdkCustomLogger)
 
-	// --- Couchbase Capella Connection Details ---
-	connectionString := "couchbases://private-endpoint.1tsdvautcakfccur.sandbox.nonprod-project-avengers.com"
-	username := "admin"
-	password := "Password@123"
-	bucketName := "travel-sample"
-	scopeName := "inventory"
+	// --- Couchbase Connection Details (from workload config) ---
+	conn := workload.Connection
+	numWorkers := defaultWorkers
+	if workload.Workers > 0 {
+		numWorkers = workload.Workers
+	}
+	duration := defaultDuration
+	if workload.Duration != "" {
+		parsed, err := time.ParseDuration(workload.Duration)
+		if err != nil {
+			fmt.Printf("FATAL: Invalid duration %q in workload config: %v\n", workload.Duration, err)
+			os.Exit(1)
+		}
+		duration = parsed
+	}
 
-	// Create a certificate pool and add the Capella CA certificate.
+	// Create a certificate pool, preferring a caCertPath override over the
+	// embedded Capella CA.
+	caPEM := []byte(ca)
+	if conn.CACertPath != "" {
+		pemBytes, err := os.ReadFile(conn.CACertPath)
+		if err != nil {
+			fmt.Printf("FATAL: Failed to read CA certificate %q: %v\n", conn.CACertPath, err)
+			os.Exit(1)
+		}
+		caPEM = pemBytes
+	}
 	certPool := x509.NewCertPool()
-	if ok := certPool.AppendCertsFromPEM([]byte(ca)); !ok {
+	if ok := certPool.AppendCertsFromPEM(caPEM); !ok {
 		fmt.Println("FATAL: Failed to append CA certificate")
 		os.Exit(1)
 	}
 
+	// --- Resolve credentials, optionally via Vault dynamic secrets ---
+	username, password := conn.Username, conn.Password
+	var vaultProvider *VaultCredentialProvider
+	if conn.Vault != nil && conn.Vault.Address != "" {
+		vaultProvider, err = NewVaultCredentialProvider(*conn.Vault)
+		if err != nil {
+			fmt.Printf("FATAL: Failed to initialize Vault credential provider: %v\n", err)
+			os.Exit(1)
+		}
+		cred := vaultProvider.Current()
+		username, password = cred.Username, cred.Password
+		appLogger.Printf("VAULT: Leased initial credential %q (lease %s, ttl %s)", username, cred.LeaseID, cred.LeaseDuration)
+	}
+
 	// --- Initialize Couchbase Cluster Connection ---
 	options := gocb.ClusterOptions{
 		Authenticator: gocb.PasswordAuthenticator{
@@ -177,35 +233,58 @@ func main() {
 		},
 	}
 
-	cluster, err := gocb.Connect(connectionString, options)
+	cluster, err := gocb.Connect(conn.ConnectionString, options)
 	if err != nil {
 		fmt.Printf("FATAL: Failed to connect to cluster: %v\n", err)
 		os.Exit(1)
 	}
-	defer cluster.Close(nil)
+	clusterHolder := NewClusterHolder(cluster)
+	defer clusterHolder.Close()
 
-	bucket := cluster.Bucket(bucketName)
+	bucket := clusterHolder.Get().Bucket(conn.BucketName)
 	if err = bucket.WaitUntilReady(30*time.Second, nil); err != nil {
 		fmt.Printf("FATAL: Failed to wait until bucket ready: %v\n", err)
 		os.Exit(1)
 	}
 
+	if vaultProvider != nil {
+		renewCtx, cancelRenew := context.WithCancel(context.Background())
+		defer cancelRenew()
+		vaultProvider.StartRenewal(renewCtx, appLogger, func(cred VaultCredential) {
+			rotatedOptions := options
+			rotatedOptions.Authenticator = gocb.PasswordAuthenticator{Username: cred.Username, Password: cred.Password}
+			newCluster, err := gocb.Connect(conn.ConnectionString, rotatedOptions)
+			if err != nil {
+				appLogger.Printf("VAULT: Failed to reconnect with rotated credential %q: %v", cred.Username, err)
+				return
+			}
+			if err := newCluster.Bucket(conn.BucketName).WaitUntilReady(30*time.Second, nil); err != nil {
+				appLogger.Printf("VAULT: Rotated connection for %q never became ready: %v", cred.Username, err)
+				newCluster.Close(nil)
+				return
+			}
+			if err := clusterHolder.Swap(newCluster); err != nil {
+				appLogger.Printf("VAULT: Error closing pre-rotation cluster connection: %v", err)
+			}
+			appLogger.Printf("VAULT: Rotated Couchbase credential to %q", cred.Username)
+		})
+	}
+
 	fmt.Printf(
 		"--- Starting concurrent query execution (SCRIPT VERSION: %s) with %d workers ---\n",
 		SCRIPT_VERSION,
 		numWorkers,
 	)
 
-	// --- Define Queries ---
-	selectQueries := []QueryInfo{
-		{Name: "Query 1 (Airline by ICAO)", Query: `SELECT name, iata, icao, callsign, country FROM ` + "`" + bucketName + "`" + `.` + scopeName + `.airline WHERE icao = 'UAL';`},
-		{Name: "Query 2 (Airport by FAA)", Query: `SELECT airportname, city, country, faa FROM ` + "`" + bucketName + "`" + `.` + scopeName + `.airport WHERE faa = 'LAX';`},
-		{Name: "Query 3 (All Routes)", Query: `SELECT sourceairport, destinationairport, airline, stops, schedule FROM ` + "`" + bucketName + "`" + `.` + scopeName + `.route LIMIT 20;`},
-		{Name: "Query 4 (Hotels in US)", Query: `SELECT name, city, country, address, description FROM ` + "`" + bucketName + "`" + `.` + scopeName + `.hotel WHERE country = 'United States' LIMIT 20;`},
+	// --- Start the Prometheus metrics endpoint ---
+	metricsPort := workload.Metrics.Port
+	if metricsPort == 0 {
+		metricsPort = 9090
 	}
-	updateHotelCities := []string{"London", "Paris", "New York", "San Francisco", "Tokyo", "Los Angeles"}
-	updateAirlineKeys := []string{"airline_8091", "airline_5225", "airline_137", "airline_410", "airline_24", "airline_10"}
-	var queryCounter uint64 = 0
+	StartMetricsServer(metricsPort)
+
+	// --- Build the weighted query mix from the workload config ---
+	picker := NewWeightedQueryPicker(workload.Queries)
 
 	tasks := make(chan QueryInfo, numWorkers*2)
 	results := make(chan QueryResult, numWorkers*2)
@@ -216,70 +295,111 @@ func main() {
 		// --- WORKER LOGIC ---
 		go func(id int) {
 			defer wg.Done()
+			activeWorkers.Inc()
+			defer activeWorkers.Dec()
 
 			for task := range tasks {
+				inFlightQueries.Inc()
+				start := time.Now()
+
 				queryOpts := &gocb.QueryOptions{NamedParameters: task.Params}
-				rows, err := cluster.Query(task.Query, queryOpts)
+				rows, err := clusterHolder.Get().Query(task.Query, queryOpts)
 
+				var result QueryResult
 				if err != nil {
-					results <- handleQueryError(id, task, err, appLogger)
-					continue
+					result = handleQueryError(id, task, err, appLogger)
+				} else {
+					var row map[string]interface{}
+					for rows.Next() {
+						_ = rows.Row(&row)
+					}
+					if err := rows.Err(); err != nil {
+						result = handleQueryError(id, task, err, appLogger)
+					} else {
+						result = QueryResult{Name: task.Name, Outcome: OutcomeSuccess}
+					}
 				}
 
-				var row map[string]interface{}
-				for rows.Next() {
-					_ = rows.Row(&row)
-				}
+				result.Latency = time.Since(start)
+				inFlightQueries.Dec()
+				queryLatencySeconds.WithLabelValues(task.Name).Observe(result.Latency.Seconds())
+				queryOutcomesTotal.WithLabelValues(task.Name, result.Outcome, result.ErrorCode).Inc()
 
-				if err := rows.Err(); err != nil {
-					results <- handleQueryError(id, task, err, appLogger)
-				} else {
-					results <- QueryResult{Name: task.Name, Outcome: OutcomeSuccess}
-				}
+				results <- result
 			}
 		}(w)
 	}
 
-	// --- Goroutine to collect categorized results ---
-	queryStats := make(map[string]*StatCounter)
-	allQueries := append(selectQueries, QueryInfo{Name: "Query 5 (Update Airline)"}, QueryInfo{Name: "Query 6 (Update Hotels)"})
-	for _, q := range allQueries {
-		queryStats[q.Name] = NewStatCounter()
+	// --- Goroutine to collect categorized results and sample them into a
+	// time series for the structured report ---
+	queryNames := make([]string, len(workload.Queries))
+	for i, q := range workload.Queries {
+		queryNames[i] = q.Name
 	}
+	collector := NewResultsCollector(queryNames)
 
 	var resultsWg sync.WaitGroup
 	resultsWg.Add(1)
 	go func() {
 		defer resultsWg.Done()
 		for result := range results {
-			stats := queryStats[result.Name]
-			switch result.Outcome {
-			case OutcomeSuccess:
-				stats.Success++
-			case OutcomeConflict:
-				stats.Conflicts++
-			case OutcomeFailure:
-				stats.Failures[result.ErrorMsg]++
+			collector.Record(result)
+		}
+	}()
+
+	sampleInterval := 10 * time.Second
+	if workload.Report.SampleInterval != "" {
+		parsed, err := time.ParseDuration(workload.Report.SampleInterval)
+		if err != nil {
+			fmt.Printf("FATAL: Invalid report.sampleInterval %q: %v\n", workload.Report.SampleInterval, err)
+			os.Exit(1)
+		}
+		sampleInterval = parsed
+	}
+
+	var timeSeries []Bucket
+	sampleDone := make(chan struct{})
+	var sampleWg sync.WaitGroup
+	sampleWg.Add(1)
+	go func() {
+		defer sampleWg.Done()
+		ticker := time.NewTicker(sampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				timeSeries = append(timeSeries, collector.Sample(now)...)
+			case <-sampleDone:
+				timeSeries = append(timeSeries, collector.Sample(time.Now())...)
+				return
 			}
 		}
 	}()
 
-	// --- Main loop to dispatch jobs ---
+	// --- Main loop to dispatch jobs, weighted by the configured query mix
+	// and paced by the configured scheduler ---
 	startTime := time.Now()
-	duration := 15 * time.Minute
 	totalQueriesDispatched := 0
 
+	scheduler, err := NewScheduler(workload.Scheduler, startTime, duration)
+	if err != nil {
+		fmt.Printf("FATAL: Failed to build scheduler: %v\n", err)
+		os.Exit(1)
+	}
+	dispatchCtx, cancelDispatch := context.WithDeadline(context.Background(), startTime.Add(duration))
+	defer cancelDispatch()
+
 	for time.Since(startTime) < duration {
-		for _, q := range selectQueries {
-			tasks <- q
-			totalQueriesDispatched++
+		if err := scheduler.Wait(dispatchCtx); err != nil {
+			break
 		}
-		queryCounter++
-		airlineKey := updateAirlineKeys[queryCounter%uint64(len(updateAirlineKeys))]
-		tasks <- QueryInfo{Name: "Query 5 (Update Airline)", Query: `UPDATE ` + "`" + bucketName + "`" + `.` + scopeName + `.airline USE KEYS $id SET name = 'Couchbase Global Airways' RETURNING META().id;`, Params: map[string]interface{}{"id": airlineKey}}
-		totalQueriesDispatched++
-		targetCity := updateHotelCities[queryCounter%uint64(len(updateHotelCities))]
-		tasks <- QueryInfo{Name: "Query 6 (Update Hotels)", Query: `UPDATE ` + "`" + bucketName + "`" + `.` + scopeName + `.hotel SET amenities = ARRAY_APPEND(IFMISSING(amenities, []), 'Free WiFi') WHERE city = $city RETURNING META().id;`, Params: map[string]interface{}{"city": targetCity}}
+		q := picker.Pick()
+		params, err := BuildParams(q)
+		if err != nil {
+			fmt.Printf("FATAL: Failed to build params for query %q: %v\n", q.Name, err)
+			os.Exit(1)
+		}
+		tasks <- QueryInfo{Name: q.Name, Query: q.N1QL, Params: params}
 		totalQueriesDispatched++
 	}
 	fmt.Println("\n--- Time limit reached. Waiting for workers to finish... ---")
@@ -288,9 +408,13 @@ func main() {
 	wg.Wait()
 	close(results)
 	resultsWg.Wait()
+	close(sampleDone)
+	sampleWg.Wait()
 
 	// --- Final Summary ---
-	elapsedSeconds := time.Since(startTime).Seconds()
+	finishTime := time.Now()
+	elapsedSeconds := finishTime.Sub(startTime).Seconds()
+	queryStats := collector.Stats()
 	fmt.Println("\n--- Query Execution Summary ---")
 
 	overallSuccess := 0
@@ -331,7 +455,7 @@ func main() {
 
 		if totalFailures > 0 {
 			fmt.Println("    └─ Failure Breakdown:")
-			for errMsg, count := range stats.Failures {
+			for errMsg, count := range stats.FailureMessages {
 				fmt.Printf("        - [%d times] %s\n", count, errMsg)
 			}
 		}
@@ -366,5 +490,25 @@ func main() {
 	fmt.Printf("\nTotal Queries Dispatched: %d in %.2f seconds\n", totalQueriesDispatched, elapsedSeconds)
 	fmt.Printf("Achieved QPS (Queries Per Second): %.2f\n", qps)
 
+	// --- Persist the structured report ---
+	outputDir := workload.Report.OutputDir
+	if outputDir == "" {
+		outputDir = "reports"
+	}
+	report := Report{
+		ScriptVersion: SCRIPT_VERSION,
+		GitCommit:     gitCommit(),
+		Config:        workload.Redacted(),
+		StartedAt:     startTime,
+		FinishedAt:    finishTime,
+		TimeSeries:    timeSeries,
+		Summary:       BuildSummary(queryStats),
+	}
+	if err := WriteReport(outputDir, report); err != nil {
+		fmt.Printf("WARN: Failed to write report to %q: %v\n", outputDir, err)
+	} else {
+		fmt.Printf("\nReport written to %s/report.json and %s/report.csv\n", outputDir, outputDir)
+	}
+
 	fmt.Println("\nCluster connection closed.")
 }
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Structured Reporting ---
+//
+// ResultsCollector samples the running per-query counters every
+// SampleInterval into an in-memory time series. At the end of the run,
+// WriteReport persists the full series plus a final summary to report.json
+// and report.csv so runs can be diffed programmatically instead of
+// screen-scraped from stdout.
+
+// ReportConfig controls periodic sampling and the on-disk report.
+type ReportConfig struct {
+	// OutputDir defaults to "reports" when unset.
+	OutputDir string `yaml:"outputDir,omitempty" json:"outputDir,omitempty"`
+	// SampleInterval defaults to "10s" when unset.
+	SampleInterval string `yaml:"sampleInterval,omitempty" json:"sampleInterval,omitempty"`
+}
+
+// Bucket is one time-series sample for one query.
+type Bucket struct {
+	Timestamp time.Time `json:"timestamp"`
+	QueryName string    `json:"queryName"`
+	Success   int       `json:"success"`
+	Conflict  int       `json:"conflict"`
+	Failure   int       `json:"failure"`
+	P50Millis float64   `json:"p50Millis"`
+	P95Millis float64   `json:"p95Millis"`
+	P99Millis float64   `json:"p99Millis"`
+}
+
+// QuerySummary is the final tally for one query, with failures keyed by
+// parsed N1QL error code rather than the raw error JSON.
+type QuerySummary struct {
+	QueryName      string         `json:"queryName"`
+	Success        int            `json:"success"`
+	Conflicts      int            `json:"conflicts"`
+	Failures       int            `json:"failures"`
+	FailuresByCode map[string]int `json:"failuresByCode"`
+}
+
+// Report is the full report.json payload for a run.
+type Report struct {
+	ScriptVersion string          `json:"scriptVersion"`
+	GitCommit     string          `json:"gitCommit,omitempty"`
+	Config        *WorkloadConfig `json:"config"`
+	StartedAt     time.Time       `json:"startedAt"`
+	FinishedAt    time.Time       `json:"finishedAt"`
+	TimeSeries    []Bucket        `json:"timeSeries"`
+	Summary       []QuerySummary  `json:"summary"`
+}
+
+// ResultsCollector accumulates per-query outcome counts and latencies, and
+// periodically samples them into time-series Buckets.
+type ResultsCollector struct {
+	mu        sync.Mutex
+	stats     map[string]*StatCounter
+	latencies map[string][]time.Duration
+	prev      map[string]StatCounter // cumulative counts as of the last Sample()
+}
+
+// NewResultsCollector seeds the collector with one StatCounter per query so
+// that queries with zero results still appear in the summary.
+func NewResultsCollector(queryNames []string) *ResultsCollector {
+	c := &ResultsCollector{
+		stats:     make(map[string]*StatCounter),
+		latencies: make(map[string][]time.Duration),
+		prev:      make(map[string]StatCounter),
+	}
+	for _, name := range queryNames {
+		c.stats[name] = NewStatCounter()
+		c.prev[name] = StatCounter{Failures: make(map[string]int)}
+	}
+	return c
+}
+
+// Record applies one query result to the running totals.
+func (c *ResultsCollector) Record(result QueryResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.stats[result.Name]
+	if !ok {
+		stats = NewStatCounter()
+		c.stats[result.Name] = stats
+		c.prev[result.Name] = StatCounter{Failures: make(map[string]int)}
+	}
+	switch result.Outcome {
+	case OutcomeSuccess:
+		stats.Success++
+	case OutcomeConflict:
+		stats.Conflicts++
+	case OutcomeFailure:
+		stats.Failures[result.ErrorCode]++
+		stats.FailureMessages[result.ErrorMsg]++
+	}
+	c.latencies[result.Name] = append(c.latencies[result.Name], result.Latency)
+}
+
+// Sample builds one round of time-series Buckets from the counts and
+// latencies observed since the previous Sample call, then resets the
+// latency buffers for the next round.
+func (c *ResultsCollector) Sample(ts time.Time) []Bucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.stats))
+	for name := range c.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buckets := make([]Bucket, 0, len(names))
+	for _, name := range names {
+		cur := c.stats[name]
+		prev := c.prev[name]
+
+		p50, p95, p99 := percentiles(c.latencies[name])
+		buckets = append(buckets, Bucket{
+			Timestamp: ts,
+			QueryName: name,
+			Success:   cur.Success - prev.Success,
+			Conflict:  cur.Conflicts - prev.Conflicts,
+			Failure:   failureTotal(cur.Failures) - failureTotal(prev.Failures),
+			P50Millis: p50,
+			P95Millis: p95,
+			P99Millis: p99,
+		})
+
+		failuresCopy := make(map[string]int, len(cur.Failures))
+		for k, v := range cur.Failures {
+			failuresCopy[k] = v
+		}
+		c.prev[name] = StatCounter{Success: cur.Success, Conflicts: cur.Conflicts, Failures: failuresCopy}
+		c.latencies[name] = nil
+	}
+	return buckets
+}
+
+// Stats returns the live per-query stats. Only safe to read once the run's
+// producer goroutines have stopped feeding Record.
+func (c *ResultsCollector) Stats() map[string]*StatCounter {
+	return c.stats
+}
+
+func failureTotal(failures map[string]int) int {
+	total := 0
+	for _, n := range failures {
+		total += n
+	}
+	return total
+}
+
+// percentiles returns the p50/p95/p99 latency in milliseconds for a set of
+// samples, sorting a copy so the caller's slice is left untouched.
+func percentiles(samples []time.Duration) (p50, p95, p99 float64) {
+	if len(samples) == 0 {
+		return 0, 0, 0
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(pct float64) float64 {
+		idx := int(pct * float64(len(sorted)-1))
+		return float64(sorted[idx]) / float64(time.Millisecond)
+	}
+	return at(0.50), at(0.95), at(0.99)
+}
+
+// BuildSummary turns the final per-query stats into the report's Summary
+// section.
+func BuildSummary(stats map[string]*StatCounter) []QuerySummary {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := make([]QuerySummary, 0, len(names))
+	for _, name := range names {
+		s := stats[name]
+		failuresByCode := make(map[string]int, len(s.Failures))
+		for code, count := range s.Failures {
+			failuresByCode[code] = count
+		}
+		summary = append(summary, QuerySummary{
+			QueryName:      name,
+			Success:        s.Success,
+			Conflicts:      s.Conflicts,
+			Failures:       failureTotal(s.Failures),
+			FailuresByCode: failuresByCode,
+		})
+	}
+	return summary
+}
+
+// WriteReport writes report.json and report.csv to dir, creating it if
+// needed.
+func WriteReport(dir string, report Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating output dir %q: %w", dir, err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling report.json: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.json"), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("writing report.json: %w", err)
+	}
+
+	csvFile, err := os.Create(filepath.Join(dir, "report.csv"))
+	if err != nil {
+		return fmt.Errorf("creating report.csv: %w", err)
+	}
+	defer csvFile.Close()
+
+	w := csv.NewWriter(csvFile)
+	defer w.Flush()
+	header := []string{"timestamp", "queryName", "success", "conflict", "failure", "p50Millis", "p95Millis", "p99Millis"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("writing report.csv header: %w", err)
+	}
+	for _, b := range report.TimeSeries {
+		row := []string{
+			b.Timestamp.Format(time.RFC3339),
+			b.QueryName,
+			strconv.Itoa(b.Success),
+			strconv.Itoa(b.Conflict),
+			strconv.Itoa(b.Failure),
+			strconv.FormatFloat(b.P50Millis, 'f', 3, 64),
+			strconv.FormatFloat(b.P95Millis, 'f', 3, 64),
+			strconv.FormatFloat(b.P99Millis, 'f', 3, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("writing report.csv row: %w", err)
+		}
+	}
+	return nil
+}
+
+// gitCommit returns the current commit hash, or "" if it can't be
+// determined (e.g. not running from a git checkout).
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentiles(t *testing.T) {
+	t.Run("empty input returns zeros", func(t *testing.T) {
+		p50, p95, p99 := percentiles(nil)
+		if p50 != 0 || p95 != 0 || p99 != 0 {
+			t.Fatalf("got (%v, %v, %v), want all zero", p50, p95, p99)
+		}
+	})
+
+	t.Run("sorted samples", func(t *testing.T) {
+		samples := []time.Duration{
+			50 * time.Millisecond,
+			10 * time.Millisecond,
+			30 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+		}
+		p50, p95, p99 := percentiles(samples)
+		if p50 != 30 {
+			t.Errorf("p50 = %v, want 30", p50)
+		}
+		if p95 != 40 {
+			t.Errorf("p95 = %v, want 40", p95)
+		}
+		if p99 != 40 {
+			t.Errorf("p99 = %v, want 40", p99)
+		}
+	})
+}
+
+func TestResultsCollector_SampleComputesDeltas(t *testing.T) {
+	c := NewResultsCollector([]string{"q1"})
+
+	c.Record(QueryResult{Name: "q1", Outcome: OutcomeSuccess, Latency: 10 * time.Millisecond})
+	c.Record(QueryResult{Name: "q1", Outcome: OutcomeConflict, Latency: 5 * time.Millisecond})
+	c.Record(QueryResult{Name: "q1", Outcome: OutcomeFailure, ErrorCode: "12009", ErrorMsg: "CAS mismatch", Latency: 15 * time.Millisecond})
+
+	buckets := c.Sample(time.Unix(0, 0))
+	if len(buckets) != 1 {
+		t.Fatalf("got %d buckets, want 1", len(buckets))
+	}
+	b := buckets[0]
+	if b.Success != 1 || b.Conflict != 1 || b.Failure != 1 {
+		t.Fatalf("got success=%d conflict=%d failure=%d, want 1/1/1", b.Success, b.Conflict, b.Failure)
+	}
+
+	// A second sample with no new records should report all-zero deltas.
+	empty := c.Sample(time.Unix(1, 0))[0]
+	if empty.Success != 0 || empty.Conflict != 0 || empty.Failure != 0 {
+		t.Fatalf("got success=%d conflict=%d failure=%d, want all zero", empty.Success, empty.Conflict, empty.Failure)
+	}
+
+	c.Record(QueryResult{Name: "q1", Outcome: OutcomeSuccess, Latency: 10 * time.Millisecond})
+	next := c.Sample(time.Unix(2, 0))[0]
+	if next.Success != 1 || next.Conflict != 0 || next.Failure != 0 {
+		t.Fatalf("got success=%d conflict=%d failure=%d, want 1/0/0", next.Success, next.Conflict, next.Failure)
+	}
+
+	stats := c.Stats()["q1"]
+	if stats.Success != 2 || stats.Conflicts != 1 {
+		t.Fatalf("got cumulative success=%d conflicts=%d, want 2/1", stats.Success, stats.Conflicts)
+	}
+	if stats.Failures["12009"] != 1 {
+		t.Fatalf("got cumulative Failures[\"12009\"]=%d, want 1", stats.Failures["12009"])
+	}
+	if stats.FailureMessages["CAS mismatch"] != 1 {
+		t.Fatalf("got cumulative FailureMessages[\"CAS mismatch\"]=%d, want 1", stats.FailureMessages["CAS mismatch"])
+	}
+}
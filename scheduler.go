@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// --- Dispatch Scheduling ---
+//
+// A Scheduler controls the pace of the dispatch loop. Wait blocks until the
+// next query should be dispatched, or returns ctx.Err() if ctx is cancelled
+// first.
+type Scheduler interface {
+	Wait(ctx context.Context) error
+}
+
+// SchedulerConfig selects and configures the dispatch scheduler. Type
+// defaults to "closed_loop" (the original open-throttle behavior).
+type SchedulerConfig struct {
+	Type      string  `yaml:"type,omitempty" json:"type,omitempty"` // closed_loop, target_rate, ramp
+	TargetQPS float64 `yaml:"targetQPS,omitempty" json:"targetQPS,omitempty"`
+	StartQPS  float64 `yaml:"startQPS,omitempty" json:"startQPS,omitempty"`
+	EndQPS    float64 `yaml:"endQPS,omitempty" json:"endQPS,omitempty"`
+}
+
+// NewScheduler builds the Scheduler selected by cfg.Type. startTime and
+// duration anchor a RampScheduler's linear ramp to the run's timeline.
+func NewScheduler(cfg SchedulerConfig, startTime time.Time, duration time.Duration) (Scheduler, error) {
+	switch cfg.Type {
+	case "", "closed_loop":
+		return ClosedLoopScheduler{}, nil
+	case "target_rate":
+		if cfg.TargetQPS <= 0 {
+			return nil, fmt.Errorf("scheduler type target_rate requires a positive targetQPS")
+		}
+		return NewTargetRateScheduler(cfg.TargetQPS), nil
+	case "ramp":
+		if cfg.StartQPS <= 0 || cfg.EndQPS <= 0 {
+			return nil, fmt.Errorf("scheduler type ramp requires positive startQPS and endQPS")
+		}
+		return NewRampScheduler(cfg.StartQPS, cfg.EndQPS, startTime, duration), nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler type %q", cfg.Type)
+	}
+}
+
+// ClosedLoopScheduler dispatches as fast as the workers can drain the task
+// channel, giving whatever QPS the cluster can sustain. This is the original
+// behavior of the dispatch loop.
+type ClosedLoopScheduler struct{}
+
+// Wait never blocks.
+func (ClosedLoopScheduler) Wait(ctx context.Context) error {
+	return nil
+}
+
+// TargetRateScheduler dispatches at a fixed target QPS using a token bucket,
+// so a run can sustain a known fixed load and observe the success rate under
+// it.
+type TargetRateScheduler struct {
+	limiter *rate.Limiter
+}
+
+// NewTargetRateScheduler builds a scheduler sustaining qps dispatches/second.
+func NewTargetRateScheduler(qps float64) *TargetRateScheduler {
+	return &TargetRateScheduler{limiter: rate.NewLimiter(rate.Limit(qps), burstFor(qps))}
+}
+
+// Wait blocks until the token bucket has a token to spend.
+func (s *TargetRateScheduler) Wait(ctx context.Context) error {
+	return s.limiter.Wait(ctx)
+}
+
+// RampScheduler linearly ramps the target QPS from StartQPS to EndQPS over
+// the run's duration, useful for finding the knee where conflicts spike.
+type RampScheduler struct {
+	startQPS  float64
+	endQPS    float64
+	startTime time.Time
+	duration  time.Duration
+	limiter   *rate.Limiter
+}
+
+// NewRampScheduler builds a scheduler that ramps from startQPS to endQPS
+// linearly between startTime and startTime+duration.
+func NewRampScheduler(startQPS, endQPS float64, startTime time.Time, duration time.Duration) *RampScheduler {
+	return &RampScheduler{
+		startQPS:  startQPS,
+		endQPS:    endQPS,
+		startTime: startTime,
+		duration:  duration,
+		limiter:   rate.NewLimiter(rate.Limit(startQPS), burstFor(startQPS)),
+	}
+}
+
+// Wait recomputes the target QPS for the current point in the ramp, updates
+// the token bucket's limit, and blocks until it has a token to spend.
+func (s *RampScheduler) Wait(ctx context.Context) error {
+	progress := 1.0
+	if s.duration > 0 {
+		progress = float64(time.Since(s.startTime)) / float64(s.duration)
+		if progress > 1 {
+			progress = 1
+		} else if progress < 0 {
+			progress = 0
+		}
+	}
+	currentQPS := s.startQPS + (s.endQPS-s.startQPS)*progress
+	s.limiter.SetLimit(rate.Limit(currentQPS))
+	s.limiter.SetBurst(burstFor(currentQPS))
+	return s.limiter.Wait(ctx)
+}
+
+// burstFor picks a token bucket burst size of at least 1 for the given rate.
+func burstFor(qps float64) int {
+	if qps < 1 {
+		return 1
+	}
+	return int(qps)
+}
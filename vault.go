@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// --- Vault Dynamic Credentials ---
+//
+// When a workload config's connection block includes a `vault` section, the
+// loader requests a short-lived Couchbase username/password from Vault's
+// vault-plugin-database-couchbase secrets engine (`database/creds/<role>`)
+// instead of using the static Username/Password fields, and keeps rotating
+// them for the lifetime of the run.
+
+// VaultConfig configures the Vault-backed dynamic credential provider. Leave
+// the whole block unset to fall back to ConnectionConfig.Username/Password.
+type VaultConfig struct {
+	Address  string `yaml:"address" json:"address"`
+	Token    string `yaml:"token,omitempty" json:"token,omitempty"`
+	RoleID   string `yaml:"roleId,omitempty" json:"roleId,omitempty"`
+	SecretID string `yaml:"secretId,omitempty" json:"secretId,omitempty"`
+	DBRole   string `yaml:"dbRole" json:"dbRole"`
+}
+
+// VaultCredential is a single username/password pair leased from Vault.
+type VaultCredential struct {
+	Username      string
+	Password      string
+	LeaseID       string
+	LeaseDuration time.Duration
+}
+
+// VaultCredentialProvider fetches and rotates Couchbase credentials from
+// Vault's database secrets engine.
+type VaultCredentialProvider struct {
+	client *vaultapi.Client
+	role   string
+
+	mu      sync.RWMutex
+	current VaultCredential
+}
+
+// NewVaultCredentialProvider authenticates against Vault (via a static token
+// or AppRole) and leases an initial credential for cfg.DBRole.
+func NewVaultCredentialProvider(cfg VaultConfig) (*VaultCredentialProvider, error) {
+	if cfg.DBRole == "" {
+		return nil, fmt.Errorf("vault config is missing dbRole")
+	}
+
+	vc := vaultapi.DefaultConfig()
+	vc.Address = cfg.Address
+	client, err := vaultapi.NewClient(vc)
+	if err != nil {
+		return nil, fmt.Errorf("creating vault client: %w", err)
+	}
+
+	token := cfg.Token
+	if token == "" {
+		if cfg.RoleID == "" || cfg.SecretID == "" {
+			return nil, fmt.Errorf("vault config requires either a token or an approle roleId/secretId")
+		}
+		secret, err := client.Logical().Write("auth/approle/login", map[string]interface{}{
+			"role_id":   cfg.RoleID,
+			"secret_id": cfg.SecretID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("approle login: %w", err)
+		}
+		if secret == nil || secret.Auth == nil {
+			return nil, fmt.Errorf("approle login returned no auth info")
+		}
+		token = secret.Auth.ClientToken
+	}
+	client.SetToken(token)
+
+	p := &VaultCredentialProvider{client: client, role: cfg.DBRole}
+	cred, err := p.fetch()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.current = cred
+	p.mu.Unlock()
+	return p, nil
+}
+
+// fetch requests a fresh credential from Vault's database/creds/<role>.
+func (p *VaultCredentialProvider) fetch() (VaultCredential, error) {
+	path := "database/creds/" + p.role
+	secret, err := p.client.Logical().Read(path)
+	if err != nil {
+		return VaultCredential{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return VaultCredential{}, fmt.Errorf("vault returned no data for %s", path)
+	}
+	username, _ := secret.Data["username"].(string)
+	password, _ := secret.Data["password"].(string)
+	if username == "" || password == "" {
+		return VaultCredential{}, fmt.Errorf("vault response for %s is missing username/password", path)
+	}
+	return VaultCredential{
+		Username:      username,
+		Password:      password,
+		LeaseID:       secret.LeaseID,
+		LeaseDuration: time.Duration(secret.LeaseDuration) * time.Second,
+	}, nil
+}
+
+// Current returns the most recently leased credential.
+func (p *VaultCredentialProvider) Current() VaultCredential {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.current
+}
+
+// StartRenewal leases a fresh credential at ~2/3 of the current lease's TTL
+// and invokes onRotate with it, looping until ctx is cancelled. Dynamic
+// database roles issue a brand new Couchbase user per lease rather than
+// renewing one in place, so rotation always fetches a new credential.
+func (p *VaultCredentialProvider) StartRenewal(ctx context.Context, appLogger *log.Logger, onRotate func(VaultCredential)) {
+	go func() {
+		for {
+			wait := p.Current().LeaseDuration * 2 / 3
+			if wait <= 0 {
+				wait = time.Minute
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			cred, err := p.fetch()
+			if err != nil {
+				appLogger.Printf("VAULT: Failed to renew credential for role %q: %v", p.role, err)
+				continue
+			}
+			p.mu.Lock()
+			p.current = cred
+			p.mu.Unlock()
+			onRotate(cred)
+		}
+	}()
+}
+
+// ClusterHolder holds the active *gocb.Cluster behind a RWMutex so it can be
+// hot-swapped when Vault rotates credentials without disrupting workers,
+// which always reach the cluster through Get().
+type ClusterHolder struct {
+	mu      sync.RWMutex
+	cluster *gocb.Cluster
+}
+
+// NewClusterHolder wraps an already-connected cluster.
+func NewClusterHolder(cluster *gocb.Cluster) *ClusterHolder {
+	return &ClusterHolder{cluster: cluster}
+}
+
+// Get returns the currently active cluster connection.
+func (h *ClusterHolder) Get() *gocb.Cluster {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cluster
+}
+
+// Swap replaces the active cluster connection with newCluster and closes the
+// one it replaced.
+func (h *ClusterHolder) Swap(newCluster *gocb.Cluster) error {
+	h.mu.Lock()
+	old := h.cluster
+	h.cluster = newCluster
+	h.mu.Unlock()
+	return old.Close(nil)
+}
+
+// Close closes the currently active cluster connection.
+func (h *ClusterHolder) Close() error {
+	return h.Get().Close(nil)
+}